@@ -1,16 +1,22 @@
 package circuitbreaker
 
 import (
+	"errors"
 	"sync/atomic"
 	"time"
 )
 
-type status uint32
+// ErrBreakerOpen 断路器处于打开状态且未提供兜底逻辑时返回
+var ErrBreakerOpen = errors.New("circuitbreaker: breaker is open")
 
+// State 断路器状态
+type State uint32
+
+// 断路器的三种状态
 const (
-	statusClosed status = iota
-	statusHalfOpen
-	statusOpen
+	Closed State = iota
+	HalfOpen
+	Open
 )
 
 // Task 任务
@@ -19,35 +25,120 @@ type Task func() error
 // Fallback 备用
 type Fallback func() error
 
+// Counts 断路器当前的请求统计
+type Counts struct {
+	Successes         uint32
+	Failures          uint32
+	LastStateChangeAt time.Time
+}
+
+// Option 断路器配置项
+type Option func(cb *CircuiBreaker)
+
+// WithOnStateChange 设置状态变更时触发的回调
+func WithOnStateChange(f func(from, to State)) Option {
+	return func(cb *CircuiBreaker) {
+		cb.onStateChange = f
+	}
+}
+
+// WithSuccessThreshold 设置半开状态下需要连续探测成功多少次才会转为关闭，默认为 1
+func WithSuccessThreshold(successThreshold uint32) Option {
+	return func(cb *CircuiBreaker) {
+		cb.successThreshold = successThreshold
+	}
+}
+
+// WithMaxProbes 设置半开状态下允许同时放行的探测请求数，默认为 1
+func WithMaxProbes(maxProbes uint32) Option {
+	return func(cb *CircuiBreaker) {
+		cb.maxProbes = maxProbes
+	}
+}
+
 // CircuiBreaker 断路器
 type CircuiBreaker struct {
-	maxErrorCount     uint32
-	retryInterval     time.Duration
-	curErrorCount     uint32
-	lastOpenTimestamp int64
-	retrying          uint32
-	status            status
+	maxErrorCount            uint32
+	retryInterval            time.Duration
+	curErrorCount            uint32
+	curSuccessCount          uint32
+	lastOpenTimestamp        int64
+	lastStateChangeTimestamp int64
+	state                    State
+	onStateChange            func(from, to State)
+	window                   *slidingWindow
+	successThreshold         uint32
+	maxProbes                uint32
+	curProbes                uint32
+	curProbeSuccessCount     uint32
+	callTimeout              time.Duration
+	isFailure                func(error) bool
 }
 
 // New 初始化断路器
-func New(maxErrorCount uint32, retryInterval time.Duration) *CircuiBreaker {
+func New(maxErrorCount uint32, retryInterval time.Duration, opts ...Option) *CircuiBreaker {
 	if retryInterval <= 0 {
 		panic("zero or negative retryInterval")
 	}
-	return &CircuiBreaker{
-		maxErrorCount: maxErrorCount,
-		retryInterval: retryInterval,
+	cb := &CircuiBreaker{
+		maxErrorCount:    maxErrorCount,
+		retryInterval:    retryInterval,
+		successThreshold: 1,
+		maxProbes:        1,
+	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	return cb
+}
+
+// NewWithWindow 初始化基于滑动窗口失败率的断路器。
+// 最近 bucketCount 个 bucketDuration 时间桶内的请求量达到 minRequests 后，
+// 一旦失败率达到 failureRatio 即触发熔断，判断依据是窗口内的失败率而非累计错误数。
+func NewWithWindow(bucketCount int, bucketDuration time.Duration, minRequests uint32, failureRatio float64, retryInterval time.Duration, opts ...Option) *CircuiBreaker {
+	if retryInterval <= 0 {
+		panic("zero or negative retryInterval")
+	}
+	if bucketCount <= 0 {
+		panic("bucketCount must be positive")
+	}
+	if bucketDuration <= 0 {
+		panic("zero or negative bucketDuration")
+	}
+	cb := &CircuiBreaker{
+		retryInterval:    retryInterval,
+		window:           newSlidingWindow(bucketCount, bucketDuration, minRequests, failureRatio),
+		successThreshold: 1,
+		maxProbes:        1,
+	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	return cb
+}
+
+// GetState 返回断路器当前状态
+func (cb *CircuiBreaker) GetState() State {
+	return State(atomic.LoadUint32((*uint32)(&cb.state)))
+}
+
+// Counts 返回断路器当前的错误/成功计数，以及最近一次状态变更的时间
+func (cb *CircuiBreaker) Counts() Counts {
+	return Counts{
+		Successes:         atomic.LoadUint32(&cb.curSuccessCount),
+		Failures:          atomic.LoadUint32(&cb.curErrorCount),
+		LastStateChangeAt: time.Unix(atomic.LoadInt64(&cb.lastStateChangeTimestamp), 0),
 	}
 }
 
 // Handle 处理任务
 func (cb *CircuiBreaker) Handle(task Task, fallback Fallback) error {
-	switch cb.getStatus() {
-	case statusOpen:
+	switch cb.GetState() {
+	case Open:
 		return cb.handleOpen(fallback)
-	case statusHalfOpen:
+	case HalfOpen:
 		return cb.handleHalfOpen(task, fallback)
-	case statusClosed:
+	case Closed:
 		return cb.handleClosed(task, fallback)
 	default:
 		panic("unexpected status")
@@ -55,19 +146,30 @@ func (cb *CircuiBreaker) Handle(task Task, fallback Fallback) error {
 }
 
 func (cb *CircuiBreaker) handleHalfOpen(task Task, fallback Fallback) error {
-	if !cb.trySetRetrying() {
+	if !cb.tryAcquireProbe() {
 		return cb.handleOpen(fallback)
 	}
-	if err := cb.handleNormal(task, fallback, false); err != nil {
-		return err
+	defer cb.releaseProbe()
+
+	if err := task(); err != nil {
+		cb.addError()
+		cb.reopen()
+		if err := fallback(); err != nil {
+			cb.addError()
+			return err
+		}
+		return nil
+	}
+	cb.addSuccess()
+	if cb.addProbeSuccess() >= cb.successThreshold {
+		cb.setClosed()
 	}
-	cb.setClosed()
-	cb.setRetryingFinish()
 	return nil
 }
 
 func (cb *CircuiBreaker) handleNormal(task Task, fallback Fallback, setOpen bool) error {
 	if err := task(); err == nil {
+		cb.addSuccess()
 		return nil
 	}
 	cb.addError()
@@ -100,38 +202,102 @@ func (cb *CircuiBreaker) handleClosed(task Task, fallback Fallback) error {
 	return nil
 }
 
-func (cb *CircuiBreaker) getStatus() status {
-	return status(atomic.LoadUint32((*uint32)(&cb.status)))
+// tryAcquireProbe 尝试占用一个半开探测名额，curProbes 即计数信号量
+func (cb *CircuiBreaker) tryAcquireProbe() bool {
+	for {
+		cur := atomic.LoadUint32(&cb.curProbes)
+		if cur >= cb.maxProbes {
+			return false
+		}
+		if atomic.CompareAndSwapUint32(&cb.curProbes, cur, cur+1) {
+			return true
+		}
+	}
 }
 
-func (cb *CircuiBreaker) trySetRetrying() bool {
-	return atomic.CompareAndSwapUint32(&cb.retrying, 0, 1)
+func (cb *CircuiBreaker) releaseProbe() {
+	atomic.AddUint32(&cb.curProbes, ^uint32(0))
 }
 
-func (cb *CircuiBreaker) setRetryingFinish() {
-	atomic.StoreUint32(&cb.retrying, 0)
+func (cb *CircuiBreaker) addProbeSuccess() uint32 {
+	return atomic.AddUint32(&cb.curProbeSuccessCount, 1)
+}
+
+// reopen 半开探测失败时立即回到 Open，并重置连续成功计数。
+// 用 CAS 限定只有仍处于 HalfOpen 时才生效，避免同一半开周期内
+// 另一个并发探测已经先一步 setClosed/reopen 之后，这个探测的
+// 结果还能再翻一次状态。
+func (cb *CircuiBreaker) reopen() {
+	if !atomic.CompareAndSwapUint32((*uint32)(&cb.state), uint32(HalfOpen), uint32(Open)) {
+		return
+	}
+	atomic.StoreUint32(&cb.curProbeSuccessCount, 0)
+	atomic.StoreInt64(&cb.lastOpenTimestamp, time.Now().Unix())
+	cb.notifyStateChange(HalfOpen, Open)
 }
 
 func (cb *CircuiBreaker) trySetHalfOpen() {
 	lastOpenTimestamp := atomic.LoadInt64(&cb.lastOpenTimestamp)
 	now := time.Now()
 	if now.Sub(time.Unix(lastOpenTimestamp, 0)) > cb.retryInterval {
-		atomic.CompareAndSwapUint32((*uint32)(&cb.status), uint32(statusOpen), uint32(statusHalfOpen))
+		if atomic.CompareAndSwapUint32((*uint32)(&cb.state), uint32(Open), uint32(HalfOpen)) {
+			atomic.StoreUint32(&cb.curErrorCount, 0)
+			atomic.StoreUint32(&cb.curSuccessCount, 0)
+			cb.notifyStateChange(Open, HalfOpen)
+		}
 	}
 }
 
+// setClosed 半开探测达到 successThreshold 后转为关闭，用 CAS 限定
+// 只有仍处于 HalfOpen 时才生效，理由同 reopen。
 func (cb *CircuiBreaker) setClosed() {
-	atomic.StoreUint32((*uint32)(&cb.status), uint32(statusClosed))
+	if !atomic.CompareAndSwapUint32((*uint32)(&cb.state), uint32(HalfOpen), uint32(Closed)) {
+		return
+	}
+	atomic.StoreUint32(&cb.curProbeSuccessCount, 0)
+	atomic.StoreUint32(&cb.curErrorCount, 0)
+	atomic.StoreUint32(&cb.curSuccessCount, 0)
+	cb.notifyStateChange(HalfOpen, Closed)
 }
 
+// trySetOpen 在累计错误数（成功不会清零）或窗口失败率达到阈值时触发熔断，用 CAS 限定
+// 只有仍处于 Closed 时才生效，避免一次迟到的失败（调用发起时还是
+// Closed，但返回时状态已经走到 HalfOpen 甚至有存活的探测）把已经
+// 前进的状态强行拖回 Open。
 func (cb *CircuiBreaker) trySetOpen() {
-	curErrorCount := atomic.LoadUint32(&cb.curErrorCount)
-	if curErrorCount >= cb.maxErrorCount {
-		atomic.StoreUint32((*uint32)(&cb.status), uint32(statusOpen))
-		atomic.StoreUint32(&cb.curErrorCount, 0)
+	if cb.window != nil {
+		if !cb.window.shouldTrip() {
+			return
+		}
+	} else if atomic.LoadUint32(&cb.curErrorCount) < cb.maxErrorCount {
+		return
+	}
+	if !atomic.CompareAndSwapUint32((*uint32)(&cb.state), uint32(Closed), uint32(Open)) {
+		return
+	}
+	atomic.StoreUint32(&cb.curErrorCount, 0)
+	atomic.StoreUint32(&cb.curSuccessCount, 0)
+	atomic.StoreInt64(&cb.lastOpenTimestamp, time.Now().Unix())
+	cb.notifyStateChange(Closed, Open)
+}
+
+func (cb *CircuiBreaker) notifyStateChange(from, to State) {
+	atomic.StoreInt64(&cb.lastStateChangeTimestamp, time.Now().Unix())
+	if cb.onStateChange != nil {
+		cb.onStateChange(from, to)
 	}
 }
 
 func (cb *CircuiBreaker) addError() {
 	atomic.AddUint32(&cb.curErrorCount, 1)
+	if cb.window != nil {
+		cb.window.recordFailure()
+	}
+}
+
+func (cb *CircuiBreaker) addSuccess() {
+	atomic.AddUint32(&cb.curSuccessCount, 1)
+	if cb.window != nil {
+		cb.window.recordSuccess()
+	}
 }
@@ -32,3 +32,68 @@ func TestCircuitBreaker(t *testing.T) {
 	}
 
 }
+
+func TestCircuitBreakerStateAndCounts(t *testing.T) {
+	var transitions []State
+	cb := New(2, time.Minute, WithOnStateChange(func(from, to State) {
+		transitions = append(transitions, to)
+	}))
+	task := func() error {
+		return errors.New("some error")
+	}
+	fallback := func() error {
+		return nil
+	}
+
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState expected:%v,got:%v", Closed, got)
+	}
+
+	cb.Handle(task, fallback)
+	cb.Handle(task, fallback)
+
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+
+	if counts := cb.Counts(); counts.Failures != 0 {
+		t.Fatalf("Failures expected:%d,got:%d", 0, counts.Failures)
+	}
+
+	if len(transitions) != 1 || transitions[0] != Open {
+		t.Fatalf("onStateChange expected one transition to Open,got:%v", transitions)
+	}
+}
+
+func TestCircuitBreakerCountsResetOnRecovery(t *testing.T) {
+	cb := New(1, time.Millisecond, WithSuccessThreshold(1))
+	fail := func() error { return errors.New("some error") }
+	succeed := func() error { return nil }
+	fallback := func() error { return nil }
+
+	cb.Handle(fail, fallback)
+	time.Sleep(2 * time.Millisecond)
+
+	// flips Open -> HalfOpen without running a probe (handleOpen never
+	// invokes task)
+	cb.Handle(fail, fallback)
+
+	// failed probe: addError() ticks curErrorCount before reopen()
+	cb.Handle(fail, fallback)
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	cb.Handle(succeed, fallback)
+
+	// successful probe closes the breaker
+	cb.Handle(succeed, fallback)
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState expected:%v,got:%v", Closed, got)
+	}
+
+	if counts := cb.Counts(); counts.Failures != 0 {
+		t.Fatalf("Failures expected:%d,got:%d (stale count from prior half-open cycle)", 0, counts.Failures)
+	}
+}
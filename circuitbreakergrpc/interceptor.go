@@ -0,0 +1,119 @@
+// Package circuitbreakergrpc 将 circuitbreaker 包装成 gRPC 客户端拦截器。
+package circuitbreakergrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/wwq-2020/circuitbreaker"
+)
+
+// Option 拦截器配置项
+type Option func(o *options)
+
+// WithIsFailureCode 设置哪些返回码应计为失败，默认 Unavailable、DeadlineExceeded、ResourceExhausted
+func WithIsFailureCode(isFailureCode func(code codes.Code) bool) Option {
+	return func(o *options) {
+		o.isFailureCode = isFailureCode
+	}
+}
+
+// WithFallback 设置断路器打开时的兜底逻辑，默认返回 circuitbreaker.ErrBreakerOpen
+func WithFallback(fallback func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.fallback = fallback
+	}
+}
+
+type options struct {
+	isFailureCode func(code codes.Code) bool
+	fallback      func(ctx context.Context) error
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		isFailureCode: defaultIsFailureCode,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func defaultIsFailureCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *options) fallbackOrBreakerOpen(ctx context.Context) error {
+	if o.fallback != nil {
+		return o.fallback(ctx)
+	}
+	return circuitbreaker.ErrBreakerOpen
+}
+
+// UnaryClientInterceptor 返回用断路器包装一元调用的客户端拦截器
+func UnaryClientInterceptor(cb *circuitbreaker.CircuiBreaker, opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts...)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		var called bool
+		var callErr error
+		task := func(ctx context.Context) error {
+			called = true
+			callErr = invoker(ctx, method, req, reply, cc, callOpts...)
+			if callErr != nil && o.isFailureCode(status.Code(callErr)) {
+				return callErr
+			}
+			return nil
+		}
+		fallback := func(ctx context.Context) error {
+			if called {
+				return nil
+			}
+			return o.fallbackOrBreakerOpen(ctx)
+		}
+
+		err := cb.HandleContext(ctx, task, fallback)
+		if called {
+			return callErr
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor 返回用断路器包装流式调用建立阶段的客户端拦截器
+func StreamClientInterceptor(cb *circuitbreaker.CircuiBreaker, opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(opts...)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var called bool
+		var callErr error
+		var stream grpc.ClientStream
+		task := func(ctx context.Context) error {
+			called = true
+			stream, callErr = streamer(ctx, desc, cc, method, callOpts...)
+			if callErr != nil && o.isFailureCode(status.Code(callErr)) {
+				return callErr
+			}
+			return nil
+		}
+		fallback := func(ctx context.Context) error {
+			if called {
+				return nil
+			}
+			return o.fallbackOrBreakerOpen(ctx)
+		}
+
+		err := cb.HandleContext(ctx, task, fallback)
+		if called {
+			return stream, callErr
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,106 @@
+package circuitbreakergrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/wwq-2020/circuitbreaker"
+)
+
+func TestUnaryClientInterceptorOpensOnFailureCode(t *testing.T) {
+	cb := circuitbreaker.New(1, time.Minute)
+	interceptor := UnaryClientInterceptor(cb)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "unavailable")
+	}
+
+	err := interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected:%v,got:%v", codes.Unavailable, err)
+	}
+	if got := cb.GetState(); got != circuitbreaker.Open {
+		t.Fatalf("GetState expected:%v,got:%v", circuitbreaker.Open, got)
+	}
+
+	called := false
+	invoker2 := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		called = true
+		return nil
+	}
+	if err := interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker2); err != circuitbreaker.ErrBreakerOpen {
+		t.Fatalf("expected:%v,got:%v", circuitbreaker.ErrBreakerOpen, err)
+	}
+	if called {
+		t.Fatalf("invoker should not be called while breaker is open")
+	}
+}
+
+func TestUnaryClientInterceptorIgnoresNonClassifiedErrors(t *testing.T) {
+	cb := circuitbreaker.New(1, time.Minute)
+	interceptor := UnaryClientInterceptor(cb)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.InvalidArgument, "bad request")
+	}
+
+	err := interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected:%v,got:%v", codes.InvalidArgument, err)
+	}
+	if got := cb.GetState(); got != circuitbreaker.Closed {
+		t.Fatalf("GetState expected:%v,got:%v", circuitbreaker.Closed, got)
+	}
+}
+
+func TestUnaryClientInterceptorWithFallback(t *testing.T) {
+	cb := circuitbreaker.New(1, time.Minute)
+	fallbackErr := errors.New("fallback")
+	interceptor := UnaryClientInterceptor(cb, WithFallback(func(ctx context.Context) error {
+		return fallbackErr
+	}))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "unavailable")
+	}
+	interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+
+	if err := interceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != fallbackErr {
+		t.Fatalf("expected:%v,got:%v", fallbackErr, err)
+	}
+}
+
+func TestStreamClientInterceptorOpensOnFailureCode(t *testing.T) {
+	cb := circuitbreaker.New(1, time.Minute)
+	interceptor := StreamClientInterceptor(cb)
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+
+	_, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/svc/Method", streamer)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected:%v,got:%v", codes.Unavailable, err)
+	}
+	if got := cb.GetState(); got != circuitbreaker.Open {
+		t.Fatalf("GetState expected:%v,got:%v", circuitbreaker.Open, got)
+	}
+
+	called := false
+	streamer2 := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		called = true
+		return nil, nil
+	}
+	if _, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/svc/Method", streamer2); err != circuitbreaker.ErrBreakerOpen {
+		t.Fatalf("expected:%v,got:%v", circuitbreaker.ErrBreakerOpen, err)
+	}
+	if called {
+		t.Fatalf("streamer should not be called while breaker is open")
+	}
+}
@@ -0,0 +1,105 @@
+// Package circuitbreakerhttp 将 circuitbreaker 包装成 http.RoundTripper，
+// 便于直接用于 *http.Client。
+package circuitbreakerhttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/wwq-2020/circuitbreaker"
+)
+
+// Option RoundTripper 配置项
+type Option func(rt *RoundTripper)
+
+// WithNext 设置被包装的下一层 RoundTripper，默认为 http.DefaultTransport
+func WithNext(next http.RoundTripper) Option {
+	return func(rt *RoundTripper) {
+		rt.next = next
+	}
+}
+
+// WithIsFailureStatus 设置哪些响应状态码应计为失败，默认 5xx 与 429
+func WithIsFailureStatus(isFailureStatus func(statusCode int) bool) Option {
+	return func(rt *RoundTripper) {
+		rt.isFailureStatus = isFailureStatus
+	}
+}
+
+// WithFallback 设置断路器打开时的兜底逻辑，默认返回 circuitbreaker.ErrBreakerOpen
+func WithFallback(fallback func(ctx context.Context, req *http.Request) (*http.Response, error)) Option {
+	return func(rt *RoundTripper) {
+		rt.fallback = fallback
+	}
+}
+
+// RoundTripper 用断路器包装 http.RoundTripper，5xx/429 等响应状态码会计入熔断阈值
+type RoundTripper struct {
+	cb              *circuitbreaker.CircuiBreaker
+	next            http.RoundTripper
+	isFailureStatus func(statusCode int) bool
+	fallback        func(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// New 用断路器包装一个 http.RoundTripper
+func New(cb *circuitbreaker.CircuiBreaker, opts ...Option) *RoundTripper {
+	rt := &RoundTripper{
+		cb:              cb,
+		next:            http.DefaultTransport,
+		isFailureStatus: defaultIsFailureStatus,
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+func defaultIsFailureStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		resp         *http.Response
+		called       bool
+		transportErr error
+	)
+	task := func(ctx context.Context) error {
+		called = true
+		r, err := rt.next.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			transportErr = err
+			return err
+		}
+		resp = r
+		if rt.isFailureStatus(r.StatusCode) {
+			return fmt.Errorf("circuitbreakerhttp: unexpected status code %d", r.StatusCode)
+		}
+		return nil
+	}
+	fallback := func(ctx context.Context) error {
+		if called {
+			return nil
+		}
+		if rt.fallback != nil {
+			r, err := rt.fallback(ctx, req)
+			resp = r
+			return err
+		}
+		return circuitbreaker.ErrBreakerOpen
+	}
+
+	err := rt.cb.HandleContext(req.Context(), task, fallback)
+	if called {
+		if transportErr != nil {
+			return nil, transportErr
+		}
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
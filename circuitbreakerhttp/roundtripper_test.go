@@ -0,0 +1,41 @@
+package circuitbreakerhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wwq-2020/circuitbreaker"
+)
+
+func TestRoundTripperOpensOn5xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cb := circuitbreaker.New(1, time.Minute)
+	rt := New(cb)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error:%v", err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error:%v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("StatusCode expected:%d,got:%d", http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	if got := cb.GetState(); got != circuitbreaker.Open {
+		t.Fatalf("GetState expected:%v,got:%v", circuitbreaker.Open, got)
+	}
+
+	if _, err := rt.RoundTrip(req); err != circuitbreaker.ErrBreakerOpen {
+		t.Fatalf("expected:%v,got:%v", circuitbreaker.ErrBreakerOpen, err)
+	}
+}
@@ -0,0 +1,131 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrCallTimeout 单次调用超过 CallTimeout 时返回的错误，并总是计入熔断阈值
+var ErrCallTimeout = errors.New("circuitbreaker: call timeout")
+
+// TaskContext 支持 context 的任务
+type TaskContext func(ctx context.Context) error
+
+// FallbackContext 支持 context 的备用方法
+type FallbackContext func(ctx context.Context) error
+
+// WithCallTimeout 设置单次调用的超时时间，超时会被记为一次失败，默认不限制
+func WithCallTimeout(callTimeout time.Duration) Option {
+	return func(cb *CircuiBreaker) {
+		cb.callTimeout = callTimeout
+	}
+}
+
+// WithIsFailure 设置错误分类器，被判定为非失败的错误不计入熔断阈值，默认所有错误都计入
+func WithIsFailure(isFailure func(error) bool) Option {
+	return func(cb *CircuiBreaker) {
+		cb.isFailure = isFailure
+	}
+}
+
+// HandleContext 支持 context 的任务处理：调用前响应取消/超时，
+// 并按 CallTimeout、IsFailure 等 Option 对单次调用做定制
+func (cb *CircuiBreaker) HandleContext(ctx context.Context, task TaskContext, fallback FallbackContext) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch cb.GetState() {
+	case Open:
+		return cb.handleOpenContext(ctx, fallback)
+	case HalfOpen:
+		return cb.handleHalfOpenContext(ctx, task, fallback)
+	case Closed:
+		return cb.handleClosedContext(ctx, task, fallback)
+	default:
+		panic("unexpected status")
+	}
+}
+
+func (cb *CircuiBreaker) handleClosedContext(ctx context.Context, task TaskContext, fallback FallbackContext) error {
+	err := cb.runTask(ctx, task)
+	if err == nil {
+		cb.addSuccess()
+		return nil
+	}
+	if cb.countsAsFailure(err) {
+		cb.addError()
+		cb.trySetOpen()
+	}
+	if err := fallback(ctx); err != nil {
+		if cb.countsAsFailure(err) {
+			cb.addError()
+			cb.trySetOpen()
+		}
+		return err
+	}
+	return nil
+}
+
+func (cb *CircuiBreaker) handleOpenContext(ctx context.Context, fallback FallbackContext) error {
+	cb.trySetHalfOpen()
+	if err := fallback(ctx); err != nil {
+		if cb.countsAsFailure(err) {
+			cb.addError()
+		}
+		return err
+	}
+	return nil
+}
+
+func (cb *CircuiBreaker) handleHalfOpenContext(ctx context.Context, task TaskContext, fallback FallbackContext) error {
+	if !cb.tryAcquireProbe() {
+		return cb.handleOpenContext(ctx, fallback)
+	}
+	defer cb.releaseProbe()
+
+	err := cb.runTask(ctx, task)
+	if err != nil {
+		if cb.countsAsFailure(err) {
+			cb.addError()
+			cb.reopen()
+		}
+		if err := fallback(ctx); err != nil {
+			if cb.countsAsFailure(err) {
+				cb.addError()
+			}
+			return err
+		}
+		return nil
+	}
+	cb.addSuccess()
+	if cb.addProbeSuccess() >= cb.successThreshold {
+		cb.setClosed()
+	}
+	return nil
+}
+
+// runTask 在 CallTimeout 范围内执行 task，超时记为 ErrCallTimeout
+func (cb *CircuiBreaker) runTask(ctx context.Context, task TaskContext) error {
+	if cb.callTimeout <= 0 {
+		return task(ctx)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, cb.callTimeout)
+	defer cancel()
+	err := task(callCtx)
+	if err != nil && callCtx.Err() == context.DeadlineExceeded {
+		return ErrCallTimeout
+	}
+	return err
+}
+
+// countsAsFailure 判断一个错误是否应计入熔断阈值，ErrCallTimeout 总是计入
+func (cb *CircuiBreaker) countsAsFailure(err error) bool {
+	if err == ErrCallTimeout {
+		return true
+	}
+	if cb.isFailure == nil {
+		return true
+	}
+	return cb.isFailure(err)
+}
@@ -0,0 +1,69 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHandleContextShortCircuitsOnCanceledContext(t *testing.T) {
+	cb := New(3, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	taskCallCount := 0
+	task := func(ctx context.Context) error {
+		taskCallCount++
+		return nil
+	}
+	fallback := func(ctx context.Context) error {
+		return nil
+	}
+
+	if err := cb.HandleContext(ctx, task, fallback); err != context.Canceled {
+		t.Fatalf("expected:%v,got:%v", context.Canceled, err)
+	}
+	if taskCallCount != 0 {
+		t.Fatalf("taskCallCount expected:%d,got:%d", 0, taskCallCount)
+	}
+}
+
+func TestHandleContextCallTimeoutCountsAsFailure(t *testing.T) {
+	cb := New(1, time.Minute, WithCallTimeout(time.Millisecond))
+	task := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	fallback := func(ctx context.Context) error {
+		return nil
+	}
+
+	if err := cb.HandleContext(context.Background(), task, fallback); err != nil {
+		t.Fatalf("unexpected error:%v", err)
+	}
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+}
+
+func TestHandleContextIsFailureExcludesExpectedErrors(t *testing.T) {
+	errValidation := errors.New("validation error")
+	cb := New(1, time.Minute, WithIsFailure(func(err error) bool {
+		return err != errValidation
+	}))
+	task := func(ctx context.Context) error {
+		return errValidation
+	}
+	fallback := func(ctx context.Context) error {
+		return nil
+	}
+
+	cb.HandleContext(context.Background(), task, fallback)
+	cb.HandleContext(context.Background(), task, fallback)
+	cb.HandleContext(context.Background(), task, fallback)
+
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState expected:%v,got:%v", Closed, got)
+	}
+}
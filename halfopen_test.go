@@ -0,0 +1,254 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerHalfOpenSuccessThreshold(t *testing.T) {
+	cb := New(1, time.Millisecond, WithSuccessThreshold(2))
+	fail := func() error { return errors.New("some error") }
+	succeed := func() error { return nil }
+	fallback := func() error { return nil }
+
+	cb.Handle(fail, fallback)
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	// the first call after retryInterval elapses only flips Open -> HalfOpen
+	// (handleOpen never invokes task), so it does not count as a probe.
+	cb.Handle(succeed, fallback)
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState expected:%v,got:%v", HalfOpen, got)
+	}
+
+	cb.Handle(succeed, fallback)
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState after one probe success expected:%v,got:%v", HalfOpen, got)
+	}
+
+	cb.Handle(succeed, fallback)
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState after second probe success expected:%v,got:%v", Closed, got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbeFailureReopens(t *testing.T) {
+	cb := New(1, time.Millisecond, WithSuccessThreshold(2))
+	fail := func() error { return errors.New("some error") }
+	fallback := func() error { return nil }
+
+	cb.Handle(fail, fallback)
+	time.Sleep(2 * time.Millisecond)
+
+	// flips Open -> HalfOpen without running a probe (see above)
+	cb.Handle(fail, fallback)
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState expected:%v,got:%v", HalfOpen, got)
+	}
+
+	cb.Handle(fail, fallback)
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState after failed probe expected:%v,got:%v", Open, got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenBoundedProbes(t *testing.T) {
+	cb := New(1, time.Millisecond, WithMaxProbes(1))
+	fail := func() error { return errors.New("some error") }
+	var fallbackCallCount int32
+	fallback := func() error {
+		atomic.AddInt32(&fallbackCallCount, 1)
+		return nil
+	}
+
+	cb.Handle(fail, fallback)
+	time.Sleep(2 * time.Millisecond)
+
+	// the Open -> HalfOpen transition only happens inside handleOpen, so
+	// this call flips the state without occupying a probe slot.
+	cb.Handle(fail, fallback)
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState expected:%v,got:%v", HalfOpen, got)
+	}
+
+	var blocked int32
+	block := make(chan struct{})
+	probe := func() error {
+		atomic.StoreInt32(&blocked, 1)
+		<-block
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		cb.Handle(probe, fallback)
+		close(done)
+	}()
+
+	for atomic.LoadInt32(&blocked) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	beforeFallbackCallCount := atomic.LoadInt32(&fallbackCallCount)
+	if err := cb.Handle(probe, fallback); err != nil {
+		t.Fatalf("second probe expected to be diverted to fallback,got err:%v", err)
+	}
+	if got := atomic.LoadInt32(&fallbackCallCount); got != beforeFallbackCallCount+1 {
+		t.Fatalf("fallbackCallCount expected:%d,got:%d", beforeFallbackCallCount+1, got)
+	}
+
+	close(block)
+	<-done
+}
+
+// a stale transition attempt from one half-open probe must not be able to
+// flip a state that a concurrent probe has already moved on from.
+func TestCircuitBreakerHalfOpenStaleReopenCannotUndoClose(t *testing.T) {
+	cb := New(1, time.Millisecond)
+	cb.state = HalfOpen
+
+	cb.setClosed()
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState expected:%v,got:%v", Closed, got)
+	}
+
+	// a probe admitted before the close reports its failure late
+	cb.reopen()
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState after stale reopen expected:%v,got:%v", Closed, got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenStaleCloseCannotUndoReopen(t *testing.T) {
+	cb := New(1, time.Millisecond)
+	cb.state = HalfOpen
+
+	cb.reopen()
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+
+	// a probe admitted before the reopen reports its success late
+	cb.setClosed()
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState after stale setClosed expected:%v,got:%v", Open, got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenConcurrentProbesMixedOutcome(t *testing.T) {
+	cb := New(1, time.Millisecond, WithSuccessThreshold(1), WithMaxProbes(2))
+	fail := func() error { return errors.New("some error") }
+	fallback := func() error { return nil }
+
+	cb.Handle(fail, fallback)
+	time.Sleep(2 * time.Millisecond)
+
+	// flips Open -> HalfOpen without occupying a probe slot (see above)
+	cb.Handle(fail, fallback)
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState expected:%v,got:%v", HalfOpen, got)
+	}
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	succeed := func() error {
+		close(started)
+		<-proceed
+		return nil
+	}
+	failLate := func() error {
+		<-started
+		return errors.New("some error")
+	}
+
+	succeedDone := make(chan struct{})
+	failDone := make(chan struct{})
+	go func() {
+		cb.Handle(succeed, fallback)
+		close(succeedDone)
+	}()
+	go func() {
+		cb.Handle(failLate, fallback)
+		close(failDone)
+	}()
+
+	<-started
+	close(proceed)
+	<-succeedDone
+	<-failDone
+
+	if got := cb.GetState(); got != Open && got != Closed {
+		t.Fatalf("GetState expected Open or Closed,got:%v", got)
+	}
+}
+
+// a straggler call that was admitted while the breaker was still Closed
+// must not be able to re-trip a state that has already moved on to a
+// live HalfOpen probe.
+func TestCircuitBreakerStaleTripCannotStompActiveProbe(t *testing.T) {
+	cb := New(1, time.Millisecond, WithSuccessThreshold(1))
+	fallback := func() error { return nil }
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	straggler := func() error {
+		close(started)
+		<-proceed
+		return errors.New("some error")
+	}
+
+	stragglerDone := make(chan struct{})
+	go func() {
+		cb.Handle(straggler, fallback)
+		close(stragglerDone)
+	}()
+	<-started
+
+	// a second, fast failure trips the breaker while the straggler is
+	// still in flight.
+	cb.Handle(func() error { return errors.New("some error") }, fallback)
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	// flips Open -> HalfOpen without occupying a probe slot (see above)
+	cb.Handle(func() error { return errors.New("some error") }, fallback)
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState expected:%v,got:%v", HalfOpen, got)
+	}
+
+	probeStarted := make(chan struct{})
+	probeProceed := make(chan struct{})
+	probe := func() error {
+		close(probeStarted)
+		<-probeProceed
+		return nil
+	}
+	probeDone := make(chan struct{})
+	go func() {
+		cb.Handle(probe, fallback)
+		close(probeDone)
+	}()
+	<-probeStarted
+
+	// the straggler's trySetOpen finally runs; it must no-op instead of
+	// stomping the live HalfOpen probe back to Open.
+	close(proceed)
+	<-stragglerDone
+	if got := cb.GetState(); got != HalfOpen {
+		t.Fatalf("GetState expected:%v,got:%v (stale straggler stomped active probe)", HalfOpen, got)
+	}
+
+	close(probeProceed)
+	<-probeDone
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState expected:%v,got:%v (successful probe dropped)", Closed, got)
+	}
+}
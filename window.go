@@ -0,0 +1,81 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// windowBucket 滑动窗口中的单个时间桶
+type windowBucket struct {
+	epoch     int64
+	successes uint32
+	failures  uint32
+}
+
+// slidingWindow 基于时间桶的滑动窗口失败率统计
+type slidingWindow struct {
+	mu             sync.Mutex
+	buckets        []windowBucket
+	bucketDuration time.Duration
+	minRequests    uint32
+	failureRatio   float64
+}
+
+func newSlidingWindow(bucketCount int, bucketDuration time.Duration, minRequests uint32, failureRatio float64) *slidingWindow {
+	return &slidingWindow{
+		buckets:        make([]windowBucket, bucketCount),
+		bucketDuration: bucketDuration,
+		minRequests:    minRequests,
+		failureRatio:   failureRatio,
+	}
+}
+
+func (w *slidingWindow) epochAt(t time.Time) int64 {
+	return t.UnixNano() / int64(w.bucketDuration)
+}
+
+func (w *slidingWindow) recordSuccess() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.currentBucket().successes++
+}
+
+func (w *slidingWindow) recordFailure() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.currentBucket().failures++
+}
+
+// currentBucket 返回当前时间所在的桶，若桶已过期则先清零，调用方需持有锁
+func (w *slidingWindow) currentBucket() *windowBucket {
+	epoch := w.epochAt(time.Now())
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		b.epoch = epoch
+		b.successes = 0
+		b.failures = 0
+	}
+	return b
+}
+
+// shouldTrip 汇总窗口内所有未过期的桶，判断失败率是否达到熔断阈值
+func (w *slidingWindow) shouldTrip() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	epoch := w.epochAt(time.Now())
+	oldest := epoch - int64(len(w.buckets)) + 1
+	var successes, failures uint32
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.epoch < oldest {
+			continue
+		}
+		successes += b.successes
+		failures += b.failures
+	}
+	total := successes + failures
+	if total < w.minRequests {
+		return false
+	}
+	return float64(failures)/float64(total) >= w.failureRatio
+}
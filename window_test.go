@@ -0,0 +1,48 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	cb := NewWithWindow(4, 10*time.Millisecond, 4, 0.5, time.Minute)
+	taskCallCount := 0
+	task := func() error {
+		taskCallCount++
+		if taskCallCount%2 == 0 {
+			return errors.New("some error")
+		}
+		return nil
+	}
+	fallback := func() error {
+		return nil
+	}
+
+	for i := 0; i < 4; i++ {
+		cb.Handle(task, fallback)
+	}
+
+	if got := cb.GetState(); got != Open {
+		t.Fatalf("GetState expected:%v,got:%v", Open, got)
+	}
+}
+
+func TestCircuitBreakerWindowBelowMinRequests(t *testing.T) {
+	cb := NewWithWindow(4, 10*time.Millisecond, 10, 0.5, time.Minute)
+	task := func() error {
+		return errors.New("some error")
+	}
+	fallback := func() error {
+		return nil
+	}
+
+	for i := 0; i < 4; i++ {
+		cb.Handle(task, fallback)
+	}
+
+	if got := cb.GetState(); got != Closed {
+		t.Fatalf("GetState expected:%v,got:%v", Closed, got)
+	}
+}